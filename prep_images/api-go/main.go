@@ -1,14 +1,24 @@
 package main
 
 import (
+	"bufio"
+	"container/list"
 	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,6 +34,18 @@ type Config struct {
 
 	PredBucket  string
 	KeyTemplate string
+
+	StreamPollFallback bool
+	StreamPollInterval time.Duration
+
+	PresignDefaultTTL time.Duration
+	PresignMaxTTL     time.Duration
+
+	CacheMaxEntries int
+	CacheMaxBytes   int64
+
+	TenantsConfigPath string
+	AdminToken        string
 }
 
 func mustConfig() Config {
@@ -39,6 +61,26 @@ func mustConfig() Config {
 	bucket := getenv("PREDICTIONS_BUCKET", "predictions")
 	keyTpl := getenv("PREDICTIONS_KEY_TEMPLATE", "{ticker}/H{h}/predictions_test.csv")
 
+	pollFallback := strings.ToLower(os.Getenv("STREAM_POLL_FALLBACK")) == "true"
+	pollSeconds, err := strconv.Atoi(getenv("STREAM_POLL_INTERVAL_SECONDS", "5"))
+	if err != nil || pollSeconds <= 0 {
+		pollSeconds = 5
+	}
+
+	presignMaxSeconds, err := strconv.Atoi(getenv("PRESIGN_MAX_TTL", "3600"))
+	if err != nil || presignMaxSeconds <= 0 {
+		presignMaxSeconds = 3600
+	}
+
+	cacheMaxEntries, err := strconv.Atoi(getenv("CACHE_MAX_ENTRIES", "256"))
+	if err != nil || cacheMaxEntries <= 0 {
+		cacheMaxEntries = 256
+	}
+	cacheMaxBytes, err := strconv.ParseInt(getenv("CACHE_MAX_BYTES", "67108864"), 10, 64)
+	if err != nil || cacheMaxBytes <= 0 {
+		cacheMaxBytes = 64 * 1024 * 1024
+	}
+
 	return Config{
 		S3Endpoint:  endpoint,
 		S3AccessKey: access,
@@ -46,6 +88,18 @@ func mustConfig() Config {
 		S3UseSSL:    useSSL,
 		PredBucket:  bucket,
 		KeyTemplate: keyTpl,
+
+		StreamPollFallback: pollFallback,
+		StreamPollInterval: time.Duration(pollSeconds) * time.Second,
+
+		PresignDefaultTTL: 15 * time.Minute,
+		PresignMaxTTL:     time.Duration(presignMaxSeconds) * time.Second,
+
+		CacheMaxEntries: cacheMaxEntries,
+		CacheMaxBytes:   cacheMaxBytes,
+
+		TenantsConfigPath: os.Getenv("TENANTS_CONFIG"),
+		AdminToken:        os.Getenv("ADMIN_TOKEN"),
 	}
 }
 
@@ -62,6 +116,29 @@ func keyFromTemplate(tpl, ticker string, h int) string {
 	return out
 }
 
+// hFromKey recovers the horizon value embedded in a key produced by
+// keyFromTemplate, so bucket-notification and polling events (which only
+// carry the object key) can be matched against a `?horizons=` filter.
+func hFromKey(tpl, ticker, key string) (int, bool) {
+	pattern := regexp.QuoteMeta(tpl)
+	pattern = strings.ReplaceAll(pattern, regexp.QuoteMeta("{ticker}"), regexp.QuoteMeta(strings.ToUpper(strings.TrimSpace(ticker))))
+	pattern = strings.ReplaceAll(pattern, regexp.QuoteMeta("{h}"), `(\d+)`)
+
+	re, err := regexp.Compile("^" + pattern + "$")
+	if err != nil {
+		return 0, false
+	}
+	m := re.FindStringSubmatch(key)
+	if m == nil {
+		return 0, false
+	}
+	h, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return h, true
+}
+
 func getenv(k, def string) string {
 	v := strings.TrimSpace(os.Getenv(k))
 	if v == "" {
@@ -118,6 +195,665 @@ func parsePredictionsCSV(r io.Reader) ([]map[string]any, error) {
 	return rows, nil
 }
 
+// rowCacheEntry holds one cached, already-parsed CSV object, keyed by its
+// ETag so a changed object can never be served from a stale entry.
+type rowCacheEntry struct {
+	cacheKey string
+	rows     []map[string]any
+	bytes    int64
+}
+
+// rowCache is an in-process LRU of parsed prediction rows, bounded by both
+// entry count and approximate byte size. It exists to avoid re-downloading
+// and re-parsing the same CSV object on every request for a hot ticker.
+type rowCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	curBytes   int64
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+func newRowCache(maxEntries int, maxBytes int64) *rowCache {
+	return &rowCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+func (c *rowCache) get(cacheKey string) ([]map[string]any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[cacheKey]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*rowCacheEntry).rows, true
+}
+
+func (c *rowCache) put(cacheKey string, rows []map[string]any, approxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[cacheKey]; ok {
+		c.curBytes -= el.Value.(*rowCacheEntry).bytes
+		c.ll.Remove(el)
+		delete(c.items, cacheKey)
+	}
+
+	entry := &rowCacheEntry{cacheKey: cacheKey, rows: rows, bytes: approxBytes}
+	c.items[cacheKey] = c.ll.PushFront(entry)
+	c.curBytes += approxBytes
+
+	for (c.ll.Len() > c.maxEntries || c.curBytes > c.maxBytes) && c.ll.Len() > 0 {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		oldestEntry := oldest.Value.(*rowCacheEntry)
+		c.curBytes -= oldestEntry.bytes
+		c.ll.Remove(oldest)
+		delete(c.items, oldestEntry.cacheKey)
+	}
+}
+
+var selectIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+var selectOpRe = regexp.MustCompile(`(>=|<=|!=|=|>|<)`)
+
+// buildSelectSQL turns `select=`, `where=` and `limit=` query params into a
+// SQL expression accepted by S3 Select against `s3object s`. Only a single
+// `col OP value` comparison is supported in `where`, which is all the
+// dashboards that call this endpoint need today.
+func buildSelectSQL(selectParam, whereParam, limitParam string) (string, error) {
+	cols := "*"
+	if selectParam != "" {
+		parts := strings.Split(selectParam, ",")
+		for i, p := range parts {
+			p = strings.TrimSpace(p)
+			if !selectIdentRe.MatchString(p) {
+				return "", fmt.Errorf("invalid select column: %q", p)
+			}
+			parts[i] = fmt.Sprintf("s.%s", p)
+		}
+		cols = strings.Join(parts, ", ")
+	}
+
+	sql := fmt.Sprintf("SELECT %s FROM s3object s", cols)
+
+	if whereParam != "" {
+		loc := selectOpRe.FindStringIndex(whereParam)
+		if loc == nil {
+			return "", fmt.Errorf("invalid where clause: %q", whereParam)
+		}
+		col := strings.TrimSpace(whereParam[:loc[0]])
+		op := whereParam[loc[0]:loc[1]]
+		val := strings.TrimSpace(whereParam[loc[1]:])
+		if !selectIdentRe.MatchString(col) || val == "" {
+			return "", fmt.Errorf("invalid where clause: %q", whereParam)
+		}
+		if _, err := strconv.ParseFloat(val, 64); err == nil {
+			sql += fmt.Sprintf(" WHERE CAST(s.%s AS FLOAT) %s %s", col, op, val)
+		} else {
+			sql += fmt.Sprintf(" WHERE s.%s %s '%s'", col, op, strings.ReplaceAll(val, "'", "''"))
+		}
+	}
+
+	if limitParam != "" {
+		n, err := strconv.Atoi(limitParam)
+		if err != nil || n <= 0 {
+			return "", fmt.Errorf("invalid limit: %q", limitParam)
+		}
+		sql += fmt.Sprintf(" LIMIT %d", n)
+	}
+
+	return sql, nil
+}
+
+// isSelectUnsupported reports whether err indicates the backend doesn't
+// implement S3 Select, as opposed to a query or connectivity failure.
+func isSelectUnsupported(err error) bool {
+	if err == nil {
+		return false
+	}
+	resp := minio.ToErrorResponse(err)
+	switch resp.Code {
+	case "NotImplemented", "MethodNotAllowed", "UnsupportedSqlOperation", "MethodNotSupported":
+		return true
+	}
+	return false
+}
+
+// queryViaFallback serves /predictions/:ticker/query by downloading and
+// parsing the whole object in-process, for backends that don't support
+// S3 Select. It applies the same select/where/limit semantics as
+// buildSelectSQL, so the same query selects the same rows either way.
+// Values keep the types parsePredictionsCSV gives them (numbers for
+// horizon_days, pred_label, proba_up and threshold), whereas S3 Select
+// returns every CSV field as a string.
+func queryViaFallback(c *gin.Context, minioClient *minio.Client, cfg Config, ticker string, h int, key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	obj, err := minioClient.GetObject(ctx, cfg.PredBucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "object not found", "bucket": cfg.PredBucket, "key": key})
+		return
+	}
+	defer obj.Close()
+
+	rows, err := parsePredictionsCSV(obj)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "csv parse error", "details": err.Error()})
+		return
+	}
+
+	rows, err = filterRows(rows, c.Query("select"), c.Query("where"), c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(c.Writer)
+	for _, row := range rows {
+		_ = enc.Encode(row)
+		c.Writer.Flush()
+	}
+}
+
+// filterRows applies the select/where/limit query params to an already
+// parsed set of rows, mirroring the semantics buildSelectSQL gives S3 Select.
+func filterRows(rows []map[string]any, selectParam, whereParam, limitParam string) ([]map[string]any, error) {
+	var cols []string
+	if selectParam != "" {
+		for _, p := range strings.Split(selectParam, ",") {
+			p = strings.TrimSpace(p)
+			if !selectIdentRe.MatchString(p) {
+				return nil, fmt.Errorf("invalid select column: %q", p)
+			}
+			cols = append(cols, p)
+		}
+	}
+
+	var col, op, val string
+	if whereParam != "" {
+		loc := selectOpRe.FindStringIndex(whereParam)
+		if loc == nil {
+			return nil, fmt.Errorf("invalid where clause: %q", whereParam)
+		}
+		col = strings.TrimSpace(whereParam[:loc[0]])
+		op = whereParam[loc[0]:loc[1]]
+		val = strings.TrimSpace(whereParam[loc[1]:])
+		if !selectIdentRe.MatchString(col) || val == "" {
+			return nil, fmt.Errorf("invalid where clause: %q", whereParam)
+		}
+	}
+
+	limit := -1
+	if limitParam != "" {
+		n, err := strconv.Atoi(limitParam)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid limit: %q", limitParam)
+		}
+		limit = n
+	}
+
+	var out []map[string]any
+	for _, row := range rows {
+		if col != "" && !rowMatches(row, col, op, val) {
+			continue
+		}
+
+		if len(cols) > 0 {
+			projected := map[string]any{}
+			for _, c := range cols {
+				projected[c] = row[c]
+			}
+			row = projected
+		}
+
+		out = append(out, row)
+		if limit >= 0 && len(out) >= limit {
+			break
+		}
+	}
+
+	return out, nil
+}
+
+func rowMatches(row map[string]any, col, op, val string) bool {
+	cell, ok := row[col]
+	if !ok {
+		return false
+	}
+
+	if cellF, isF := cell.(float64); isF {
+		valF, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return false
+		}
+		switch op {
+		case ">":
+			return cellF > valF
+		case "<":
+			return cellF < valF
+		case ">=":
+			return cellF >= valF
+		case "<=":
+			return cellF <= valF
+		case "=":
+			return cellF == valF
+		case "!=":
+			return cellF != valF
+		}
+		return false
+	}
+
+	if cellI, isI := cell.(int); isI {
+		valF, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return false
+		}
+		cellF := float64(cellI)
+		switch op {
+		case ">":
+			return cellF > valF
+		case "<":
+			return cellF < valF
+		case ">=":
+			return cellF >= valF
+		case "<=":
+			return cellF <= valF
+		case "=":
+			return cellF == valF
+		case "!=":
+			return cellF != valF
+		}
+		return false
+	}
+
+	cellS := fmt.Sprintf("%v", cell)
+	if _, err := strconv.ParseFloat(val, 64); err == nil {
+		if cellF, err := strconv.ParseFloat(cellS, 64); err == nil {
+			return rowMatches(map[string]any{col: cellF}, col, op, val)
+		}
+		return false
+	}
+	switch op {
+	case "=":
+		return cellS == val
+	case "!=":
+		return cellS != val
+	case ">":
+		return cellS > val
+	case "<":
+		return cellS < val
+	case ">=":
+		return cellS >= val
+	case "<=":
+		return cellS <= val
+	default:
+		return false
+	}
+}
+
+// parseHorizonsFilter turns a `?horizons=1,3,7` query param into a lookup
+// set. An empty param means "no filter, accept every horizon".
+func parseHorizonsFilter(param string) (map[int]bool, error) {
+	if param == "" {
+		return nil, nil
+	}
+	set := map[int]bool{}
+	for _, p := range strings.Split(param, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		h, err := strconv.Atoi(p)
+		if err != nil || h <= 0 {
+			return nil, fmt.Errorf("invalid horizons value: %q", p)
+		}
+		set[h] = true
+	}
+	return set, nil
+}
+
+// emitForecastEvent fetches key, parses it, and writes a `forecast` SSE
+// event carrying the first row. It's shared by the notification and
+// polling code paths behind /predictions/:ticker/stream.
+func emitForecastEvent(c *gin.Context, minioClient *minio.Client, cfg Config, h int, key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	obj, err := minioClient.GetObject(ctx, cfg.PredBucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return
+	}
+	defer obj.Close()
+
+	rows, err := parsePredictionsCSV(obj)
+	if err != nil || len(rows) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(gin.H{"h": h, "key": key, "data": rows[0]})
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(c.Writer, "event: forecast\ndata: %s\n\n", payload)
+	c.Writer.Flush()
+}
+
+// pollForNewObjects is the fallback path for backends that don't emit
+// bucket notifications: it periodically lists the ticker prefix and emits
+// an event whenever a key's ETag changes from what was last observed.
+// The initial listing only primes `seen`, matching the notification path's
+// behavior of never replaying objects that existed before subscribe.
+func pollForNewObjects(ctx context.Context, c *gin.Context, minioClient *minio.Client, cfg Config, prefix string, horizons map[int]bool) {
+	seen := map[string]string{}
+	listChangedKeys(ctx, minioClient, cfg, prefix, seen)
+
+	ticker := time.NewTicker(cfg.StreamPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, obj := range listChangedKeys(ctx, minioClient, cfg, prefix, seen) {
+				h, ok := hFromKey(cfg.KeyTemplate, strings.TrimSuffix(prefix, "/"), obj.Key)
+				if !ok || (horizons != nil && !horizons[h]) {
+					continue
+				}
+				emitForecastEvent(c, minioClient, cfg, h, obj.Key)
+			}
+		}
+	}
+}
+
+// listChangedKeys lists the objects under prefix and returns the ones whose
+// ETag differs from (or is absent from) seen, updating seen in place as it
+// goes. Shared by pollForNewObjects' priming pass and its recurring tick so
+// both use identical listing options and change-detection logic.
+func listChangedKeys(ctx context.Context, minioClient *minio.Client, cfg Config, prefix string, seen map[string]string) []minio.ObjectInfo {
+	listCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var changed []minio.ObjectInfo
+	for obj := range minioClient.ListObjects(listCtx, cfg.PredBucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			continue
+		}
+		if seen[obj.Key] == obj.ETag {
+			continue
+		}
+		seen[obj.Key] = obj.ETag
+		changed = append(changed, obj)
+	}
+	return changed
+}
+
+// parseTTL reads a `?ttl=` query param in seconds, falling back to
+// cfg.PresignDefaultTTL and capping at cfg.PresignMaxTTL.
+func parseTTL(cfg Config, ttlParam string) (time.Duration, error) {
+	ttl := cfg.PresignDefaultTTL
+	if ttlParam != "" {
+		seconds, err := strconv.Atoi(ttlParam)
+		if err != nil || seconds <= 0 {
+			return 0, fmt.Errorf("ttl must be a positive integer number of seconds")
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if ttl > cfg.PresignMaxTTL {
+		ttl = cfg.PresignMaxTTL
+	}
+	return ttl, nil
+}
+
+// reqParamsFromQuery builds the response-header overrides S3 presigned
+// URLs support, from the matching `response-content-*` query params.
+func reqParamsFromQuery(c *gin.Context) url.Values {
+	v := url.Values{}
+	if cd := c.Query("response-content-disposition"); cd != "" {
+		v.Set("response-content-disposition", cd)
+	}
+	if ct := c.Query("response-content-type"); ct != "" {
+		v.Set("response-content-type", ct)
+	}
+	return v
+}
+
+// fetchRows serves the post-stat half of statAndFetchRows: it checks the
+// cache against info's ETag and, on a miss, downloads and parses the
+// object. Callers that already have an ObjectInfo (e.g. because they
+// called StatObject themselves to handle a conditional GET) should call
+// this directly instead of statAndFetchRows, to avoid a redundant
+// StatObject round trip for the same object on the same request.
+func fetchRows(ctx context.Context, minioClient *minio.Client, cache *rowCache, bucket, key string, info minio.ObjectInfo) ([]map[string]any, error) {
+	etag := `"` + strings.Trim(info.ETag, `"`) + `"`
+	cacheKey := bucket + "|" + key + "|" + etag
+	if cached, ok := cache.get(cacheKey); ok {
+		return cached, nil
+	}
+
+	obj, err := minioClient.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	rows, err := parsePredictionsCSV(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.put(cacheKey, rows, info.Size)
+	return rows, nil
+}
+
+// forecastHorizonStat is the StatObject result for one horizon's key under
+// /forecast/:ticker, kept around so the composite validator can be built
+// before any object is downloaded.
+type forecastHorizonStat struct {
+	h    int
+	key  string
+	info minio.ObjectInfo
+	err  error
+}
+
+// compositeForecastValidator derives an aggregate ETag and Last-Modified
+// for /forecast/:ticker from its per-horizon object stats. The endpoint has
+// no single backing object, so a strong ETag isn't meaningful; instead a
+// weak ETag (RFC 7232 §2.3) is derived from a hash of each horizon's own
+// ETag (or "missing" if the object doesn't exist), and Last-Modified is the
+// most recent per-horizon timestamp. Any per-horizon change - including an
+// object appearing or disappearing - changes the hash.
+func compositeForecastValidator(stats []forecastHorizonStat) (string, time.Time) {
+	h := sha256.New()
+	var lastModified time.Time
+	for _, st := range stats {
+		if st.err != nil {
+			fmt.Fprintf(h, "%d:missing\n", st.h)
+			continue
+		}
+		fmt.Fprintf(h, "%d:%s\n", st.h, strings.Trim(st.info.ETag, `"`))
+		if st.info.LastModified.After(lastModified) {
+			lastModified = st.info.LastModified
+		}
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)) + `"`, lastModified
+}
+
+// statAndFetchRows resolves bucket/key to parsed rows, using StatObject to
+// find the current ETag and serving from cache on an ETag hit so a hot
+// ticker's CSV is downloaded and parsed at most once per object version.
+func statAndFetchRows(ctx context.Context, minioClient *minio.Client, cache *rowCache, bucket, key string) ([]map[string]any, minio.ObjectInfo, error) {
+	info, err := minioClient.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, minio.ObjectInfo{}, err
+	}
+
+	rows, err := fetchRows(ctx, minioClient, cache, bucket, key, info)
+	if err != nil {
+		return nil, minio.ObjectInfo{}, err
+	}
+	return rows, info, nil
+}
+
+// TenantConfig describes one tenant's MinIO endpoint/bucket/credentials, as
+// loaded from the JSON file or mounted Secret pointed to by TENANTS_CONFIG.
+type TenantConfig struct {
+	Endpoint    string `json:"endpoint"`
+	UseSSL      bool   `json:"useSSL"`
+	Bucket      string `json:"bucket"`
+	KeyTemplate string `json:"keyTemplate"`
+
+	// CredentialsProvider selects how AccessKey/SecretKey are turned into
+	// MinIO credentials: "static" (default), "sts", or "iam".
+	CredentialsProvider string `json:"credentialsProvider"`
+	AccessKey           string `json:"accessKey"`
+	SecretKey           string `json:"secretKey"`
+
+	// Only used when CredentialsProvider is "sts".
+	STSEndpoint string `json:"stsEndpoint"`
+	RoleARN     string `json:"roleArn"`
+}
+
+type tenantsFile struct {
+	Tenants map[string]TenantConfig `json:"tenants"`
+}
+
+func loadTenantConfigs(path string) (map[string]TenantConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f tenantsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if f.Tenants == nil {
+		return nil, fmt.Errorf("%s: no \"tenants\" object found", path)
+	}
+	return f.Tenants, nil
+}
+
+func credsForTenant(t TenantConfig) (*credentials.Credentials, error) {
+	switch t.CredentialsProvider {
+	case "", "static":
+		return credentials.NewStaticV4(t.AccessKey, t.SecretKey, ""), nil
+	case "iam":
+		return credentials.NewIAM(""), nil
+	case "sts":
+		return credentials.NewSTSAssumeRole(t.STSEndpoint, credentials.STSAssumeRoleOptions{
+			AccessKey: t.AccessKey,
+			SecretKey: t.SecretKey,
+			RoleARN:   t.RoleARN,
+		})
+	default:
+		return nil, fmt.Errorf("unknown credentialsProvider %q", t.CredentialsProvider)
+	}
+}
+
+func newMinioForTenant(t TenantConfig) (*minio.Client, error) {
+	creds, err := credsForTenant(t)
+	if err != nil {
+		return nil, err
+	}
+	return minio.New(t.Endpoint, &minio.Options{Creds: creds, Secure: t.UseSSL})
+}
+
+// tenantStore holds the live *minio.Client and config per tenant, reloaded
+// wholesale on SIGHUP from TenantsConfigPath. Reads take the read lock so
+// in-flight requests are never blocked on each other, only on a reload.
+type tenantStore struct {
+	mu      sync.RWMutex
+	configs map[string]TenantConfig
+	clients map[string]*minio.Client
+}
+
+func newTenantStore() *tenantStore {
+	return &tenantStore{configs: map[string]TenantConfig{}, clients: map[string]*minio.Client{}}
+}
+
+func (s *tenantStore) reload(path string) error {
+	configs, err := loadTenantConfigs(path)
+	if err != nil {
+		return err
+	}
+
+	clients := make(map[string]*minio.Client, len(configs))
+	for name, tc := range configs {
+		client, err := newMinioForTenant(tc)
+		if err != nil {
+			return fmt.Errorf("tenant %q: %w", name, err)
+		}
+		clients[name] = client
+	}
+
+	s.mu.Lock()
+	s.configs = configs
+	s.clients = clients
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *tenantStore) get(name string) (*minio.Client, TenantConfig, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	client, ok := s.clients[name]
+	if !ok {
+		return nil, TenantConfig{}, false
+	}
+	return client, s.configs[name], true
+}
+
+func (s *tenantStore) list() map[string]TenantConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]TenantConfig, len(s.configs))
+	for k, v := range s.configs {
+		out[k] = v
+	}
+	return out
+}
+
+// watchTenantsReload reloads the tenant store from path whenever the
+// process receives SIGHUP, so credentials/bucket changes can be rolled out
+// without a restart.
+func watchTenantsReload(store *tenantStore, path string) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	for range sighup {
+		if err := store.reload(path); err != nil {
+			fmt.Fprintf(os.Stderr, "tenants reload failed: %v\n", err)
+		}
+	}
+}
+
+func requireBearerToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" || got != token {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
 func main() {
 	cfg := mustConfig()
 
@@ -126,6 +862,16 @@ func main() {
 		panic(fmt.Errorf("minio client init error: %w", err))
 	}
 
+	cache := newRowCache(cfg.CacheMaxEntries, cfg.CacheMaxBytes)
+
+	tenants := newTenantStore()
+	if cfg.TenantsConfigPath != "" {
+		if err := tenants.reload(cfg.TenantsConfigPath); err != nil {
+			fmt.Fprintf(os.Stderr, "tenants config load failed: %v\n", err)
+		}
+		go watchTenantsReload(tenants, cfg.TenantsConfigPath)
+	}
+
 	r := gin.Default()
 
 	r.GET("/health", func(c *gin.Context) {
@@ -136,19 +882,84 @@ func main() {
 		if err != nil {
 			c.JSON(http.StatusServiceUnavailable, gin.H{
 				"status": "error",
-				"minio":   "unreachable",
-				"error":   err.Error(),
+				"minio":  "unreachable",
+				"error":  err.Error(),
 			})
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"status":           "ok",
+			"status":            "ok",
 			"predictionsBucket": cfg.PredBucket,
-			"bucketExists":     exists,
+			"bucketExists":      exists,
+		})
+	})
+
+	// MULTI-TENANT PREDICTIONS (per-tenant MinIO endpoint/bucket/creds)
+	r.GET("/t/:tenant/predictions/:ticker", func(c *gin.Context) {
+		tenantClient, tenantCfg, ok := tenants.get(c.Param("tenant"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown tenant", "tenant": c.Param("tenant")})
+			return
+		}
+
+		ticker := c.Param("ticker")
+		hStr := c.Query("h")
+		if hStr == "" {
+			hStr = "1"
+		}
+		h, err := strconv.Atoi(hStr)
+		if err != nil || h <= 0 || h > 365 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "h must be a positive integer"})
+			return
+		}
+
+		key := keyFromTemplate(tenantCfg.KeyTemplate, ticker, h)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		rows, _, err := statAndFetchRows(ctx, tenantClient, cache, tenantCfg.Bucket, key)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "object not found", "bucket": tenantCfg.Bucket, "key": key})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"tenant": c.Param("tenant"),
+			"ticker": ticker,
+			"h":      h,
+			"bucket": tenantCfg.Bucket,
+			"key":    key,
+			"count":  len(rows),
+			"data":   rows,
 		})
 	})
 
+	// ADMIN: list configured tenants and their reachability
+	r.GET("/tenants", requireBearerToken(cfg.AdminToken), func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		out := gin.H{}
+		for name, tc := range tenants.list() {
+			client, _, _ := tenants.get(name)
+			reachable := false
+			if client != nil {
+				if exists, err := client.BucketExists(ctx, tc.Bucket); err == nil {
+					reachable = exists
+				}
+			}
+			out[name] = gin.H{
+				"endpoint":  tc.Endpoint,
+				"bucket":    tc.Bucket,
+				"reachable": reachable,
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"tenants": out})
+	})
+
 	// JSON
 	r.GET("/predictions/:ticker", func(c *gin.Context) {
 		ticker := c.Param("ticker")
@@ -167,20 +978,22 @@ func main() {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		obj, err := minioClient.GetObject(ctx, cfg.PredBucket, key, minio.GetObjectOptions{})
+		info, err := minioClient.StatObject(ctx, cfg.PredBucket, key, minio.StatObjectOptions{})
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "object not found", "bucket": cfg.PredBucket, "key": key})
 			return
 		}
-		defer obj.Close()
 
-		_, statErr := obj.Stat()
-		if statErr != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "object not found", "bucket": cfg.PredBucket, "key": key})
+		etag := `"` + strings.Trim(info.ETag, `"`) + `"`
+		c.Header("ETag", etag)
+		c.Header("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+
+		if inm := c.GetHeader("If-None-Match"); inm != "" && inm == etag {
+			c.Status(http.StatusNotModified)
 			return
 		}
 
-		rows, err := parsePredictionsCSV(obj)
+		rows, err := fetchRows(ctx, minioClient, cache, cfg.PredBucket, key, info)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "csv parse error", "details": err.Error()})
 			return
@@ -196,7 +1009,153 @@ func main() {
 		})
 	})
 
-		// LIST OBJECTS (debug)
+	// SERVER-SIDE FILTERED QUERY (S3 Select)
+	r.GET("/predictions/:ticker/query", func(c *gin.Context) {
+		ticker := c.Param("ticker")
+		hStr := c.Query("h")
+		if hStr == "" {
+			hStr = "1"
+		}
+		h, err := strconv.Atoi(hStr)
+		if err != nil || h <= 0 || h > 365 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "h must be a positive integer"})
+			return
+		}
+
+		sql, err := buildSelectSQL(c.Query("select"), c.Query("where"), c.Query("limit"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if c.Query("EXPLAIN") == "1" {
+			c.JSON(http.StatusOK, gin.H{"sql": sql})
+			return
+		}
+
+		key := keyFromTemplate(cfg.KeyTemplate, ticker, h)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		selectResp, err := minioClient.SelectObjectContent(ctx, cfg.PredBucket, key, minio.SelectObjectOptions{
+			Expression:     sql,
+			ExpressionType: minio.QueryExpressionTypeSQL,
+			InputSerialization: minio.SelectObjectInputSerialization{
+				CSV: &minio.CSVInputOptions{
+					FileHeaderInfo: minio.CSVFileHeaderInfoUse,
+				},
+			},
+			OutputSerialization: minio.SelectObjectOutputSerialization{
+				JSON: &minio.JSONOutputOptions{
+					RecordDelimiter: "\n",
+				},
+			},
+		})
+		if isSelectUnsupported(err) {
+			queryViaFallback(c, minioClient, cfg, ticker, h, key)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "object not found", "bucket": cfg.PredBucket, "key": key})
+			return
+		}
+		defer selectResp.Close()
+
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Writer.Flush()
+
+		scanner := bufio.NewScanner(selectResp)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if len(line) == 0 {
+				continue
+			}
+			c.Writer.Write(line)
+			c.Writer.Write([]byte("\n"))
+			c.Writer.Flush()
+		}
+	})
+
+	// PRESIGNED DOWNLOAD URL
+	r.GET("/predictions/:ticker/url", func(c *gin.Context) {
+		ticker := c.Param("ticker")
+		hStr := c.Query("h")
+		if hStr == "" {
+			hStr = "1"
+		}
+		h, err := strconv.Atoi(hStr)
+		if err != nil || h <= 0 || h > 365 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "h must be a positive integer"})
+			return
+		}
+
+		ttl, err := parseTTL(cfg, c.Query("ttl"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		key := keyFromTemplate(cfg.KeyTemplate, ticker, h)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		u, err := minioClient.PresignedGetObject(ctx, cfg.PredBucket, key, ttl, reqParamsFromQuery(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "presign error", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"url":       u.String(),
+			"expiresAt": time.Now().Add(ttl).UTC(),
+			"key":       key,
+			"bucket":    cfg.PredBucket,
+		})
+	})
+
+	// PRESIGNED UPLOAD URL
+	r.POST("/predictions/:ticker/upload-url", func(c *gin.Context) {
+		ticker := c.Param("ticker")
+		hStr := c.Query("h")
+		if hStr == "" {
+			hStr = "1"
+		}
+		h, err := strconv.Atoi(hStr)
+		if err != nil || h <= 0 || h > 365 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "h must be a positive integer"})
+			return
+		}
+
+		ttl, err := parseTTL(cfg, c.Query("ttl"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		key := keyFromTemplate(cfg.KeyTemplate, ticker, h)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		u, err := minioClient.PresignedPutObject(ctx, cfg.PredBucket, key, ttl)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "presign error", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"url":       u.String(),
+			"expiresAt": time.Now().Add(ttl).UTC(),
+			"key":       key,
+			"bucket":    cfg.PredBucket,
+		})
+	})
+
+	// LIST OBJECTS (debug)
 	r.GET("/objects", func(c *gin.Context) {
 		prefix := c.Query("prefix")
 
@@ -223,7 +1182,7 @@ func main() {
 		})
 	})
 
-		// FORECAST
+	// FORECAST
 	r.GET("/forecast/:ticker", func(c *gin.Context) {
 		ticker := strings.ToUpper(strings.TrimSpace(c.Param("ticker")))
 		hmaxStr := c.Query("hmax")
@@ -239,30 +1198,37 @@ func main() {
 		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 		defer cancel()
 
-		var out []gin.H
+		stats := make([]forecastHorizonStat, 0, hmax)
 		for h := 1; h <= hmax; h++ {
 			key := fmt.Sprintf("%s/H%d/forecast_latest.csv", ticker, h)
+			info, err := minioClient.StatObject(ctx, cfg.PredBucket, key, minio.StatObjectOptions{})
+			stats = append(stats, forecastHorizonStat{h: h, key: key, info: info, err: err})
+		}
 
-			obj, err := minioClient.GetObject(ctx, cfg.PredBucket, key, minio.GetObjectOptions{})
-			if err != nil {
-				out = append(out, gin.H{"h": h, "key": key, "error": "object not found"})
-				continue
-			}
-			_, statErr := obj.Stat()
-			if statErr != nil {
-				_ = obj.Close()
-				out = append(out, gin.H{"h": h, "key": key, "error": "object not found"})
+		etag, lastModified := compositeForecastValidator(stats)
+		c.Header("ETag", etag)
+		if !lastModified.IsZero() {
+			c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+		}
+		if inm := c.GetHeader("If-None-Match"); inm != "" && inm == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		var out []gin.H
+		for _, st := range stats {
+			if st.err != nil {
+				out = append(out, gin.H{"h": st.h, "key": st.key, "error": "object not found"})
 				continue
 			}
 
-			rows, perr := parsePredictionsCSV(obj)
-			_ = obj.Close()
-			if perr != nil || len(rows) == 0 {
-				out = append(out, gin.H{"h": h, "key": key, "error": "csv parse error"})
+			rows, err := fetchRows(ctx, minioClient, cache, cfg.PredBucket, st.key, st.info)
+			if err != nil || len(rows) == 0 {
+				out = append(out, gin.H{"h": st.h, "key": st.key, "error": "csv parse error"})
 				continue
 			}
 
-			out = append(out, gin.H{"h": h, "key": key, "data": rows[0]})
+			out = append(out, gin.H{"h": st.h, "key": st.key, "data": rows[0]})
 		}
 
 		c.JSON(http.StatusOK, gin.H{
@@ -273,6 +1239,92 @@ func main() {
 		})
 	})
 
+	// PRESIGNED FORECAST DOWNLOAD URL
+	r.GET("/forecast/:ticker/url", func(c *gin.Context) {
+		ticker := strings.ToUpper(strings.TrimSpace(c.Param("ticker")))
+		hStr := c.Query("h")
+		if hStr == "" {
+			hStr = "1"
+		}
+		h, err := strconv.Atoi(hStr)
+		if err != nil || h <= 0 || h > 30 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "h must be 1..30"})
+			return
+		}
+
+		ttl, err := parseTTL(cfg, c.Query("ttl"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		key := fmt.Sprintf("%s/H%d/forecast_latest.csv", ticker, h)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		u, err := minioClient.PresignedGetObject(ctx, cfg.PredBucket, key, ttl, reqParamsFromQuery(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "presign error", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"url":       u.String(),
+			"expiresAt": time.Now().Add(ttl).UTC(),
+			"key":       key,
+			"bucket":    cfg.PredBucket,
+		})
+	})
+
+	// LIVE FORECAST SUBSCRIPTION (SSE)
+	r.GET("/predictions/:ticker/stream", func(c *gin.Context) {
+		ticker := strings.ToUpper(strings.TrimSpace(c.Param("ticker")))
+		prefix := ticker + "/"
+
+		horizons, err := parseHorizonsFilter(c.Query("horizons"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		ctx := c.Request.Context()
+
+		if cfg.StreamPollFallback {
+			pollForNewObjects(ctx, c, minioClient, cfg, prefix, horizons)
+			return
+		}
+
+		events := minioClient.ListenBucketNotification(ctx, cfg.PredBucket, prefix, "", []string{"s3:ObjectCreated:*"})
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case info, ok := <-events:
+				if !ok {
+					return
+				}
+				if info.Err != nil {
+					continue
+				}
+				for _, rec := range info.Records {
+					key := rec.S3.Object.Key
+					h, ok := hFromKey(cfg.KeyTemplate, ticker, key)
+					if !ok || (horizons != nil && !horizons[h]) {
+						continue
+					}
+					emitForecastEvent(c, minioClient, cfg, h, key)
+				}
+			}
+		}
+	})
+
 	port := getenv("PORT", "8080")
 	_ = r.Run("0.0.0.0:" + port)
-}
\ No newline at end of file
+}